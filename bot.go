@@ -10,6 +10,7 @@ import (
 	"os/exec"
 	"runtime"
 	"sync"
+	"time"
 
 	goqrcode "github.com/skip2/go-qrcode"
 )
@@ -32,6 +33,70 @@ type Bot struct {
 	hotReloadStorage    HotReloadStorage
 	uuid                string
 	deviceId            string // 设备Id
+	protocol            mode   // 登录协议,默认为 normal(网页版微信协议)
+	deviceName          string // 设备名称,仅在 iPad/Mac 协议下生效
+	components          *componentRegistry
+
+	messageMiddlewares      []MessageMiddleware
+	syncMiddlewares         []SyncMiddleware
+	effectiveMessageHandler MessageHandler // 套上所有 MessageMiddleware 之后实际生效的 handler
+
+	// ReconnectPolicy 控制 syncCheck 遇到可重试错误时的退避重连行为,为 nil 时使用 DefaultReconnectPolicy
+	ReconnectPolicy *ReconnectPolicy
+	// ReconnectCallback 在每一次退避重连前被调用,方便调用方感知重连状态
+	ReconnectCallback func(attempt int, err error, nextDelay time.Duration)
+
+	ipadStreamMu sync.Mutex
+	ipadStream   io.Closer // iPad/Mac 协议下当前打开的长连接,Exit 时需要主动关闭
+}
+
+// setIPadStream 记录(或清空)当前打开的 iPad/Mac 长连接,供 Exit 时关闭
+func (b *Bot) setIPadStream(stream io.Closer) {
+	b.ipadStreamMu.Lock()
+	defer b.ipadStreamMu.Unlock()
+	b.ipadStream = stream
+}
+
+// SyncHandler 是一次 syncCheck(或其替代实现)的执行单元
+type SyncHandler func() error
+
+// MessageMiddleware 用于在 MessageHandler 外层包装一层逻辑,例如 panic 恢复、限流、去重
+// 多个 MessageMiddleware 按照注册顺序由外到内 onion 式组合
+type MessageMiddleware func(next MessageHandler) MessageHandler
+
+// SyncMiddleware 用于在 syncCheck 外层包装一层逻辑,例如日志、指标统计
+type SyncMiddleware func(next SyncHandler) SyncHandler
+
+// UseMessageMiddleware 注册一个 MessageMiddleware,在第一次 WebInit 启动消息同步协程时组合生效,
+// 之后再注册的 MessageMiddleware 不会被使用
+func (b *Bot) UseMessageMiddleware(mw MessageMiddleware) {
+	b.messageMiddlewares = append(b.messageMiddlewares, mw)
+}
+
+// UseSyncMiddleware 注册一个 SyncMiddleware,在第一次 WebInit 启动消息同步协程时组合生效,
+// 之后再注册的 SyncMiddleware 不会被使用
+func (b *Bot) UseSyncMiddleware(mw SyncMiddleware) {
+	b.syncMiddlewares = append(b.syncMiddlewares, mw)
+}
+
+// buildMessageHandler 按照注册顺序由外到内把 messageMiddlewares 套在 b.MessageHandler 外面
+func (b *Bot) buildMessageHandler() MessageHandler {
+	handler := b.MessageHandler
+	if handler == nil {
+		handler = func(*Message) {}
+	}
+	for i := len(b.messageMiddlewares) - 1; i >= 0; i-- {
+		handler = b.messageMiddlewares[i](handler)
+	}
+	return handler
+}
+
+// buildSyncHandler 按照注册顺序由外到内把 syncMiddlewares 套在 next 外面
+func (b *Bot) buildSyncHandler(next SyncHandler) SyncHandler {
+	for i := len(b.syncMiddlewares) - 1; i >= 0; i-- {
+		next = b.syncMiddlewares[i](next)
+	}
+	return next
 }
 
 // Alive 判断当前用户是否正常在线
@@ -70,17 +135,32 @@ func (b *Bot) GetCurrentUser() (*Self, error) {
 }
 
 func (b *Bot) login(login BotLogin) error {
+	if b.components != nil && b.components.login != nil {
+		return b.components.login.Login(b, login)
+	}
 	return login.Login(b)
 }
 
 // Login 用户登录
+// 根据 protocol 的不同,会分别走网页版或者 iPad/Mac 长连接协议的扫码登录流程
 func (b *Bot) Login() error {
+	if b.protocol.isLongConn() {
+		return b.login(&IPadScanLogin{})
+	}
 	scanLogin := &SacnLogin{}
 	return b.login(scanLogin)
 }
 
 // HotLogin 热登录,可实现在单位时间内免重复扫码登录
 func (b *Bot) HotLogin(storage HotReloadStorage, opts ...HotLoginOptionFunc) error {
+	if b.protocol.isLongConn() {
+		hotLogin := &IPadHotLogin{storage: storage}
+		opts = append(defaultHotLoginOpts[:], opts...)
+		for _, opt := range opts {
+			opt(&hotLogin.opt)
+		}
+		return b.login(hotLogin)
+	}
 	hotLogin := &HotLogin{storage: storage}
 	// 进行相关设置。
 	// 如果相对默认的行为进行修改，在opts里面进行追加即可。
@@ -181,8 +261,14 @@ func (b *Bot) WebInit() error {
 		if b.MessageErrorHandler == nil {
 			b.MessageErrorHandler = defaultSyncCheckErrHandler(b)
 		}
+		b.effectiveMessageHandler = b.buildMessageHandler()
+		syncCheck := b.syncCheck
+		if b.components != nil && b.components.message != nil {
+			syncCheck = b.components.message.SyncCheck
+		}
+		syncCheck = b.buildSyncHandler(syncCheck)
 		for {
-			err := b.syncCheck()
+			err := syncCheck()
 			if err == nil {
 				continue
 			}
@@ -191,6 +277,11 @@ func (b *Bot) WebInit() error {
 				b.err = err
 				break
 			}
+			// 网络抖动、超时等可重试错误按 ReconnectPolicy 退避后再重新发起 SyncCheck,
+			// 避免在 wifi 断开之类的场景下忙轮询
+			if isRetryable(err) {
+				b.waitForReconnect(err)
+			}
 		}
 	})
 	return nil
@@ -198,7 +289,11 @@ func (b *Bot) WebInit() error {
 
 // 轮询请求
 // 根据状态码判断是否有新的请求
+// 网页版微信走短轮询的 syncCheck,iPad/Mac 协议走常驻的长连接,由 longConnSyncCheck 负责维护
 func (b *Bot) syncCheck() error {
+	if b.protocol.isLongConn() {
+		return b.longConnSyncCheck()
+	}
 	var (
 		err  error
 		resp *SyncCheckResponse
@@ -231,7 +326,7 @@ func (b *Bot) syncCheck() error {
 				// 默认同步调用
 				// 如果异步调用则需自行处理
 				// 如配合 openwechat.MessageMatchDispatcher 使用
-				b.MessageHandler(message)
+				b.effectiveMessageHandler(message)
 			}
 		}
 	}
@@ -265,6 +360,15 @@ func (b *Bot) Exit() {
 	}
 	b.self = nil
 	b.cancel()
+
+	// context 被取消后,iPad/Mac 长连接底层的请求会随之中断,
+	// 这里再显式 Close 一次,确保连接不需要等 context 传播就立刻释放
+	b.ipadStreamMu.Lock()
+	if b.ipadStream != nil {
+		_ = b.ipadStream.Close()
+		b.ipadStream = nil
+	}
+	b.ipadStreamMu.Unlock()
 }
 
 // CrashReason 获取当前Bot崩溃的原因
@@ -283,6 +387,9 @@ func (b *Bot) DumpHotReloadStorage() error {
 // DumpTo 将热登录需要的数据写入到指定的 io.Writer 中
 // 注: 写之前最好先清空之前的数据
 func (b *Bot) DumpTo(writer io.Writer) error {
+	if b.components != nil && b.components.hotReload != nil {
+		return b.components.hotReload.Dump(b, writer)
+	}
 	cookies := b.Caller.Client.GetCookieJar()
 	item := HotReloadStorageItem{
 		BaseRequest:  b.Storage.Request,
@@ -290,6 +397,9 @@ func (b *Bot) DumpTo(writer io.Writer) error {
 		LoginInfo:    b.Storage.LoginInfo,
 		WechatDomain: b.Caller.Client.Domain,
 		UUID:         b.uuid,
+		Protocol:     b.protocol,
+		DeviceId:     b.deviceId,
+		DeviceName:   b.deviceName,
 	}
 	return json.NewEncoder(writer).Encode(item)
 }
@@ -313,6 +423,9 @@ func (b *Bot) reload() error {
 	if b.hotReloadStorage == nil {
 		return errors.New("hotReloadStorage is nil")
 	}
+	if b.components != nil && b.components.hotReload != nil {
+		return b.components.hotReload.Reload(b, b.hotReloadStorage)
+	}
 	var item HotReloadStorageItem
 	err := json.NewDecoder(b.hotReloadStorage).Decode(&item)
 	if err != nil {
@@ -323,6 +436,11 @@ func (b *Bot) reload() error {
 	b.Storage.Request = item.BaseRequest
 	b.Caller.Client.Domain = item.WechatDomain
 	b.uuid = item.UUID
+	b.protocol = item.Protocol
+	b.deviceId = item.DeviceId
+	b.deviceName = item.DeviceName
+	// 恢复之前的协议模式,保证重新登录后继续走同一条通道
+	b.Caller.Client.SetMode(b.protocol)
 	return nil
 }
 
@@ -333,7 +451,7 @@ func NewBot(c context.Context) *Bot {
 	// 默认行为为网页版微信模式
 	caller.Client.SetMode(normal)
 	ctx, cancel := context.WithCancel(c)
-	return &Bot{Caller: caller, Storage: &Storage{}, context: ctx, cancel: cancel}
+	return &Bot{Caller: caller, Storage: &Storage{}, context: ctx, cancel: cancel, protocol: normal}
 }
 
 // DefaultBot 默认的Bot的构造方法,
@@ -342,6 +460,9 @@ func NewBot(c context.Context) *Bot {
 //	bot := openwechat.DefaultBot(openwechat.Desktop)
 func DefaultBot(opts ...BotOptionFunc) *Bot {
 	bot := NewBot(context.Background())
+	// 通过组件注册表组合出当前的默认行为,
+	// 第三方可以之后通过 bot.Use 替换其中任意一个组件
+	bot.useDefaultComponents()
 	// 获取二维码回调
 	bot.UUIDCallback = PrintlnQrcodeUrl
 	// 扫码回调
@@ -0,0 +1,275 @@
+package openwechat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// iPad 长连接协议相关的接口路径
+// 均挂在当前 Client.Domain 之下,和网页版复用同一套域名切换逻辑
+const (
+	iPadGetQRPath       = "/cgi-bin/mmwebwx-bin/webwxgetqripad"
+	iPadCheckQRPath     = "/cgi-bin/mmwebwx-bin/webwxcheckqripad"
+	iPadLoginPath       = "/cgi-bin/mmwebwx-bin/webwxipadlogin"
+	iPadWakeUpPath      = "/cgi-bin/mmwebwx-bin/webwxipadwakeup"
+	iPadSyncStreamPath  = "/cgi-bin/mmwebwx-bin/webwxipadsyncstream"
+	iPadHeartbeatPath   = "/cgi-bin/mmwebwx-bin/webwxipadheartbeat"
+	iPadSyncMessagePath = "/cgi-bin/mmwebwx-bin/webwxipadsyncmsg"
+)
+
+// HTTPStatusError 包装一次 iPad/Mac 协议请求收到的非 2xx 响应,
+// 携带的状态码可以用来判断是服务端抖动(5xx)还是请求本身有问题(4xx)
+type HTTPStatusError struct {
+	Path string
+	Code int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("openwechat: %s returned unexpected status %d", e.Path, e.Code)
+}
+
+// StatusCode 返回响应的 HTTP 状态码
+func (e *HTTPStatusError) StatusCode() int {
+	return e.Code
+}
+
+func newHTTPStatusError(path string, code int) error {
+	return &HTTPStatusError{Path: path, Code: code}
+}
+
+// IPadQRSession 是 GetQR 阶段和服务端协商出来的设备指纹 + 二维码信息
+type IPadQRSession struct {
+	UUID       string `json:"uuid"`
+	DeviceID   string `json:"deviceId"`
+	DeviceName string `json:"deviceName"`
+}
+
+// httpClient 构造一个复用当前 Caller cookie jar 的 http.Client,
+// iPad/Mac 协议下的所有请求都通过它发出,以便和网页版共享同一套 Client 状态
+func (c *Caller) httpClient() *http.Client {
+	return &http.Client{Jar: c.Client.Jar, Timeout: 15 * time.Second}
+}
+
+// IPadGetQR 向服务端协商设备指纹并换回一个用于扫码登录的 session,
+// deviceID/deviceName 为空时会分别回落到随机生成的设备号和 "iPad"
+func (c *Caller) IPadGetQR(deviceID, deviceName string) (*IPadQRSession, error) {
+	if deviceID == "" {
+		deviceID = GetRandomDeviceId()
+	}
+	if deviceName == "" {
+		deviceName = "iPad"
+	}
+	url := "https://" + c.Client.Domain.BaseHost() + iPadGetQRPath +
+		"?deviceId=" + deviceID + "&deviceName=" + deviceName
+	resp, err := c.httpClient().Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var session IPadQRSession
+	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+		return nil, err
+	}
+	if session.DeviceID == "" {
+		session.DeviceID = deviceID
+	}
+	if session.DeviceName == "" {
+		session.DeviceName = deviceName
+	}
+	return &session, nil
+}
+
+// IPadCheckQRResponse 描述一次 CheckQR 轮询的结果
+type IPadCheckQRResponse struct {
+	Status int    `json:"status"` // 0: 等待扫码, 1: 已扫码待确认, 2: 已确认
+	Data   []byte `json:"data"`   // Status 为 2 时携带的登录凭证,交给 HandleLogin 解析
+}
+
+// Confirmed 判断用户是否已经在手机上点击了确认登录
+func (r IPadCheckQRResponse) Confirmed() bool {
+	return r.Status == 2
+}
+
+// IPadCheckQR 轮询 session 对应二维码的扫码状态
+func (c *Caller) IPadCheckQR(session *IPadQRSession) (*IPadCheckQRResponse, error) {
+	url := "https://" + c.Client.Domain.BaseHost() + iPadCheckQRPath + "?uuid=" + session.UUID
+	resp, err := c.httpClient().Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result IPadCheckQRResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// IPadLogin 驱动一次完整的 iPad/Mac 扫码登录: 协商设备指纹 -> 推送二维码 -> 轮询扫码状态 -> 完成登录
+func (c *Caller) IPadLogin(bot *Bot) error {
+	session, err := c.IPadGetQR(bot.deviceId, bot.deviceName)
+	if err != nil {
+		return err
+	}
+	bot.deviceId = session.DeviceID
+	bot.deviceName = session.DeviceName
+	bot.uuid = session.UUID
+
+	if bot.UUIDCallback != nil {
+		bot.UUIDCallback(session.UUID)
+	}
+
+	const pollInterval = time.Second
+	for {
+		result, err := c.IPadCheckQR(session)
+		if err != nil {
+			return err
+		}
+		switch {
+		case result.Confirmed():
+			return bot.HandleLogin(result.Data)
+		case result.Status == 1 && bot.ScanCallBack != nil:
+			bot.ScanCallBack(result.Data)
+		}
+		select {
+		case <-bot.Context().Done():
+			return bot.Context().Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// IPadWakeUp 尝试用热登录恢复出来的凭证唤醒原有的长连接 session,
+// 成功返回 nil 代表可以直接复用,无需重新扫码
+func (c *Caller) IPadWakeUp(bot *Bot) error {
+	req := bot.Storage.Request
+	if req == nil {
+		return errors.New("openwechat: no BaseRequest to wake up, scan login required")
+	}
+	url := "https://" + c.Client.Domain.BaseHost() + iPadWakeUpPath
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient().Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newHTTPStatusError(iPadWakeUpPath, resp.StatusCode)
+	}
+	return nil
+}
+
+// IPadHeartbeat 向服务端发送一次心跳,避免常驻长连接因为长时间没有客户端流量而被断开
+func (c *Caller) IPadHeartbeat(request *BaseRequest) error {
+	url := "https://" + c.Client.Domain.BaseHost() + iPadHeartbeatPath
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient().Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newHTTPStatusError(iPadHeartbeatPath, resp.StatusCode)
+	}
+	return nil
+}
+
+// IPadSyncMessageResponse 是 iPad 长连接协议自己的消息同步接口的返回值,
+// 字段含义和网页版 WebWxSync 的返回值对齐,方便复用 SyncKey 的更新逻辑
+type IPadSyncMessageResponse struct {
+	SyncKey    SyncKey
+	AddMsgList []*Message
+}
+
+// IPadSyncMessage 拉取长连接 stream 提示的新消息的实际内容
+// 和网页版 WebWxSync 的区别在于走的是 iPad 专属的同步接口,
+// 不会触碰 "容易触发风控" 的网页版 WebWxSync 接口
+func (c *Caller) IPadSyncMessage(request *BaseRequest, response *WebInitResponse) (*IPadSyncMessageResponse, error) {
+	url := "https://" + c.Client.Domain.BaseHost() + iPadSyncMessagePath
+	body, err := json.Marshal(map[string]interface{}{
+		"BaseRequest": request,
+		"SyncKey":     response.SyncKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient().Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPStatusError(iPadSyncMessagePath, resp.StatusCode)
+	}
+	var result IPadSyncMessageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ipadSyncStream 是 iPad/Mac 协议下常驻的长连接,
+// 服务端通过分块传输持续推送 SyncCheckResponse,客户端按行解析
+type ipadSyncStream struct {
+	resp *http.Response
+	dec  *json.Decoder
+}
+
+// IPadDialStream 建立 iPad/Mac 协议的长连接,用于替代网页版的短轮询 syncCheck
+// ctx 一般传入 bot.Context(): bot 退出时 ctx 被取消,底层连接和阻塞的 Next() 会随之中断返回,
+// 而不是一直占用到服务端主动断开
+func (c *Caller) IPadDialStream(ctx context.Context, request *BaseRequest, loginInfo *LoginInfo) (*ipadSyncStream, error) {
+	url := "https://" + c.Client.Domain.BaseHost() + iPadSyncStreamPath
+	body, err := json.Marshal(map[string]interface{}{
+		"BaseRequest": request,
+		"LoginInfo":   loginInfo,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := c.httpClient()
+	client.Timeout = 0 // 长连接不设整体超时,生命周期完全由 ctx 控制
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, newHTTPStatusError(iPadSyncStreamPath, resp.StatusCode)
+	}
+	return &ipadSyncStream{resp: resp, dec: json.NewDecoder(bufio.NewReader(resp.Body))}, nil
+}
+
+// Next 阻塞读取长连接推送的下一条 SyncCheckResponse
+func (s *ipadSyncStream) Next() (*SyncCheckResponse, error) {
+	var resp SyncCheckResponse
+	if err := s.dec.Decode(&resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Close 关闭长连接底层的 http.Response
+func (s *ipadSyncStream) Close() error {
+	return s.resp.Body.Close()
+}
@@ -0,0 +1,147 @@
+package openwechat
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Component 是 Bot 可插拔组件的统一入口
+// 第三方可以实现自己的 Component 并通过 bot.Use 注册进来,
+// 替换掉登录方式、热登录存储、消息同步循环等内置实现,而无需 fork 本仓库
+type Component interface {
+	// Attach 在组件注册时调用,用于和 bot 建立关联或者做必要的初始化
+	Attach(bot *Bot) error
+}
+
+// LoginComponent 负责登录流程,替换默认的扫码/热登录/免扫码登录实现
+type LoginComponent interface {
+	Component
+	// Login 接管 Bot.Login/HotLogin/PushLogin 底层的登录分发
+	Login(bot *Bot, login BotLogin) error
+}
+
+// HotReloadComponent 负责热登录数据的持久化与恢复
+// 可以用 Redis、etcd 等替换默认的基于 io.Writer/io.Reader 的存储
+type HotReloadComponent interface {
+	Component
+	Dump(bot *Bot, writer io.Writer) error
+	Reload(bot *Bot, reader io.Reader) error
+}
+
+// MessageComponent 负责消息同步循环,替换默认的 syncCheck 短轮询
+// 例如替换为 websocket 长连接推送
+type MessageComponent interface {
+	Component
+	SyncCheck() error
+}
+
+// componentRegistry 持有 Bot 当前生效的各类组件
+// 为 nil 的字段表示使用 Bot 自身的默认实现
+//
+// 注意: 这是原计划里 contact / hotreload / login / messages / register 五类组件的缩小版,
+// 只落地了 login(LoginComponent)、hotreload(HotReloadComponent)、messages(MessageComponent)
+// 三类。contact、register 这两类故意没做: 联系人/好友获取和账号注册目前都还是 Bot 上直接调用
+// Caller 的内联逻辑,没有拆出任何可以让 Component 接管的调用点,硬做一个没有调用点的接口只是
+// 摆设。等这两块各自被拆成独立、可替换的入口之后再补上对应的 Component,而不是现在就为了凑满
+// 五类而引入空实现
+type componentRegistry struct {
+	login     LoginComponent
+	hotReload HotReloadComponent
+	message   MessageComponent
+}
+
+// Use 向 Bot 注册一个 Component
+// 根据 Component 实际实现的接口,将其放入对应的槽位,
+// 同一类型的组件重复注册时,后者会覆盖前者
+func (b *Bot) Use(component Component) error {
+	if err := component.Attach(b); err != nil {
+		return err
+	}
+	if b.components == nil {
+		b.components = &componentRegistry{}
+	}
+	if c, ok := component.(LoginComponent); ok {
+		b.components.login = c
+	}
+	if c, ok := component.(HotReloadComponent); ok {
+		b.components.hotReload = c
+	}
+	if c, ok := component.(MessageComponent); ok {
+		b.components.message = c
+	}
+	return nil
+}
+
+// defaultLoginComponent 组合了 Bot 当前默认的登录行为,
+// 使 DefaultBot 在没有用户自定义组件时表现和重构前完全一致
+type defaultLoginComponent struct{}
+
+func (defaultLoginComponent) Attach(*Bot) error { return nil }
+
+func (defaultLoginComponent) Login(bot *Bot, login BotLogin) error {
+	return login.Login(bot)
+}
+
+// defaultMessageComponent 组合了 Bot 默认的 syncCheck 短轮询行为
+type defaultMessageComponent struct {
+	bot *Bot
+}
+
+func (c *defaultMessageComponent) Attach(bot *Bot) error {
+	c.bot = bot
+	return nil
+}
+
+func (c *defaultMessageComponent) SyncCheck() error {
+	return c.bot.syncCheck()
+}
+
+// defaultHotReloadComponent 组合了 Bot 默认的热登录存储行为
+type defaultHotReloadComponent struct {
+	bot *Bot
+}
+
+func (c *defaultHotReloadComponent) Attach(bot *Bot) error {
+	c.bot = bot
+	return nil
+}
+
+func (c *defaultHotReloadComponent) Dump(bot *Bot, writer io.Writer) error {
+	cookies := bot.Caller.Client.GetCookieJar()
+	item := HotReloadStorageItem{
+		BaseRequest:  bot.Storage.Request,
+		Jar:          cookies,
+		LoginInfo:    bot.Storage.LoginInfo,
+		WechatDomain: bot.Caller.Client.Domain,
+		UUID:         bot.uuid,
+		Protocol:     bot.protocol,
+		DeviceId:     bot.deviceId,
+		DeviceName:   bot.deviceName,
+	}
+	return json.NewEncoder(writer).Encode(item)
+}
+
+func (c *defaultHotReloadComponent) Reload(bot *Bot, reader io.Reader) error {
+	var item HotReloadStorageItem
+	if err := json.NewDecoder(reader).Decode(&item); err != nil {
+		return err
+	}
+	bot.Caller.Client.Jar = item.Jar.AsCookieJar()
+	bot.Storage.LoginInfo = item.LoginInfo
+	bot.Storage.Request = item.BaseRequest
+	bot.Caller.Client.Domain = item.WechatDomain
+	bot.uuid = item.UUID
+	bot.protocol = item.Protocol
+	bot.deviceId = item.DeviceId
+	bot.deviceName = item.DeviceName
+	bot.Caller.Client.SetMode(bot.protocol)
+	return nil
+}
+
+// useDefaultComponents 把 Bot 现有的默认行为包装成 componentRegistry,
+// 保证 DefaultBot 在没有 opts 传入自定义 Component 时行为和重构前一致
+func (b *Bot) useDefaultComponents() {
+	_ = b.Use(defaultLoginComponent{})
+	_ = b.Use(&defaultMessageComponent{})
+	_ = b.Use(&defaultHotReloadComponent{})
+}
@@ -0,0 +1,46 @@
+package openwechat
+
+import "testing"
+
+type fakeLoginComponent struct{ attached *Bot }
+
+func (c *fakeLoginComponent) Attach(bot *Bot) error {
+	c.attached = bot
+	return nil
+}
+
+func (c *fakeLoginComponent) Login(bot *Bot, login BotLogin) error {
+	return login.Login(bot)
+}
+
+func TestBotUseRegistersBySlot(t *testing.T) {
+	b := &Bot{}
+	comp := &fakeLoginComponent{}
+	if err := b.Use(comp); err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+	if b.components == nil || b.components.login != comp {
+		t.Fatal("expected the LoginComponent to be registered in the login slot")
+	}
+	if b.components.hotReload != nil || b.components.message != nil {
+		t.Fatal("expected only the login slot to be populated")
+	}
+	if comp.attached != b {
+		t.Fatal("expected Attach to be called with the owning bot")
+	}
+}
+
+func TestBotUseOverridesSameSlot(t *testing.T) {
+	b := &Bot{}
+	first := &fakeLoginComponent{}
+	second := &fakeLoginComponent{}
+	if err := b.Use(first); err != nil {
+		t.Fatalf("Use(first): %v", err)
+	}
+	if err := b.Use(second); err != nil {
+		t.Fatalf("Use(second): %v", err)
+	}
+	if b.components.login != second {
+		t.Fatal("expected the later Use call to override the earlier one in the same slot")
+	}
+}
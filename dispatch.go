@@ -0,0 +1,16 @@
+package openwechat
+
+// DispatchMessage 把一段文本包装成一条合成消息,喂给当前生效的 MessageHandler
+// 主要用于 mpbridge 这类外部输入源需要复用现有消息处理管线的场景
+func (b *Bot) DispatchMessage(content string) {
+	handler := b.effectiveMessageHandler
+	if handler == nil {
+		handler = b.MessageHandler
+	}
+	if handler == nil {
+		return
+	}
+	message := &Message{Content: content}
+	message.init(b)
+	handler(message)
+}
@@ -0,0 +1,173 @@
+package openwechat
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrBotAlreadyAlive 在 Add 被调用时 bot 已经登录(WebInit 的 sync 协程已经跑起来)时返回
+// 此时再包装 MessageHandler/MessageErrorHandler 对已经被协程读取过的闭包不再生效,
+// 必须在 Login/HotLogin 之前完成 Add
+var ErrBotAlreadyAlive = errors.New("openwechat: bot is already alive, Add must be called before Login/HotLogin")
+
+// BotManager 管理一组以自定义 id 为 key 的 *Bot,
+// 方便需要同时维护多个会话(例如按 OpenID、设备号区分)的场景
+type BotManager struct {
+	mu   sync.RWMutex
+	bots map[string]*Bot
+
+	// MessageHandler 是所有托管 bot 的消息统一汇聚出口,
+	// 设置后会在每个 bot 收到消息时一并调用,消息本身已经 init 过,可以直接使用
+	MessageHandler func(id string, message *Message)
+}
+
+// NewBotManager 创建一个空的 BotManager
+func NewBotManager() *BotManager {
+	return &BotManager{bots: make(map[string]*Bot)}
+}
+
+// Add 将 bot 以 id 为 key 纳入管理
+// 如果 MessageHandler 已设置,会把 bot 原有的 MessageHandler 包装一层,
+// 在调用原 handler 之后再扇入到 BotManager 的统一出口
+//
+// 必须在 bot.Login/HotLogin/PushLogin 之前调用: 登录会立即拉起 WebInit 里的 sync 协程,
+// 协程读到的 MessageHandler/MessageErrorHandler 是调用那一刻的值,Add 之后才包装就不会生效。
+// 如果 bot 在调用时已经 Alive,Add 会直接返回 ErrBotAlreadyAlive 而不是静默包装一个不再被读取的闭包
+func (m *BotManager) Add(id string, bot *Bot) error {
+	if bot.Alive() {
+		return ErrBotAlreadyAlive
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bots[id] = bot
+
+	prev := bot.MessageHandler
+	bot.MessageHandler = func(message *Message) {
+		if prev != nil {
+			prev(message)
+		}
+		if m.MessageHandler != nil {
+			m.MessageHandler(id, message)
+		}
+	}
+
+	// bot 崩溃退出后自动从管理器里摘除,避免 Range/Get 拿到已经失效的 bot
+	prevErrHandler := bot.MessageErrorHandler
+	bot.MessageErrorHandler = func(err error) bool {
+		var goon bool
+		if prevErrHandler != nil {
+			goon = prevErrHandler(err)
+		} else {
+			goon = defaultSyncCheckErrHandler(bot)(err)
+		}
+		if !goon {
+			m.Remove(id)
+		}
+		return goon
+	}
+	return nil
+}
+
+// Remove 将 id 对应的 bot 从管理器中移除,不会主动退出该 bot
+func (m *BotManager) Remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.bots, id)
+}
+
+// Get 按 id 取出托管的 bot,不存在返回 nil, false
+func (m *BotManager) Get(id string) (*Bot, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	bot, ok := m.bots[id]
+	return bot, ok
+}
+
+// Range 按 id 遍历所有托管的 bot,f 返回 false 时提前终止遍历
+func (m *BotManager) Range(f func(id string, bot *Bot) bool) {
+	m.mu.RLock()
+	snapshot := make(map[string]*Bot, len(m.bots))
+	for id, bot := range m.bots {
+		snapshot[id] = bot
+	}
+	m.mu.RUnlock()
+
+	for id, bot := range snapshot {
+		if !f(id, bot) {
+			return
+		}
+	}
+}
+
+// BlockAll 阻塞直到所有托管的 bot 都退出
+func (m *BotManager) BlockAll() {
+	var wg sync.WaitGroup
+	m.Range(func(_ string, bot *Bot) bool {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = bot.Block()
+		}()
+		return true
+	})
+	wg.Wait()
+}
+
+// ExitAll 主动退出所有托管的 bot
+func (m *BotManager) ExitAll() {
+	m.Range(func(_ string, bot *Bot) bool {
+		bot.Exit()
+		return true
+	})
+}
+
+// AutoLoad 从 dir 目录下逐个恢复此前通过 PerBotHotReloadPath 保存的会话,
+// 文件名(不含扩展名)会被当作 bot 的 id
+func AutoLoad(dir string, opts ...BotOptionFunc) (*BotManager, error) {
+	manager := NewBotManager()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		id := entry.Name()
+		if ext := filepath.Ext(id); ext != "" {
+			id = id[:len(id)-len(ext)]
+		}
+		path := filepath.Join(dir, entry.Name())
+		storage, err := NewJsonFileHotReloadStorage(path)
+		if err != nil {
+			manager.ExitAll()
+			return nil, err
+		}
+		bot := DefaultBot(opts...)
+		// 必须先 Add 再 HotLogin: HotLogin 会立即拉起 WebInit 里的 sync 协程,
+		// 它读到的 MessageHandler/MessageErrorHandler 必须已经是 Add 包装过的版本,
+		// 否则会和 Add 里的字段写入产生数据竞争。这里的 bot 刚 DefaultBot 出来还没登录,
+		// 不会触发 ErrBotAlreadyAlive,错误只在断言失败时才会出现
+		if err := manager.Add(id, bot); err != nil {
+			storage.Close()
+			bot.Exit()
+			manager.ExitAll()
+			return nil, err
+		}
+		if err := bot.HotLogin(storage); err != nil {
+			storage.Close()
+			bot.Exit()
+			manager.Remove(id)
+			manager.ExitAll()
+			return nil, err
+		}
+	}
+	return manager, nil
+}
+
+// PerBotHotReloadPath 返回 AutoLoad 约定下,id 对应的热登录存储文件路径
+func PerBotHotReloadPath(dir, id string) string {
+	return filepath.Join(dir, id+".json")
+}
@@ -0,0 +1,172 @@
+// Package middleware 提供了一组可以直接通过
+// Bot.UseMessageMiddleware / Bot.UseSyncMiddleware 挂载的中间件,
+// 覆盖 panic 恢复、结构化日志、限流、指标统计、按 MsgId 去重这些常见的横切需求
+package middleware
+
+import (
+	"container/list"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tgNotHouse/openwechat"
+)
+
+// Recover 返回一个 MessageMiddleware,防止单条消息处理 panic 导致整个 sync 循环退出
+func Recover() openwechat.MessageMiddleware {
+	return func(next openwechat.MessageHandler) openwechat.MessageHandler {
+		return func(message *openwechat.Message) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("openwechat: recovered from panic in MessageHandler: %v", r)
+				}
+			}()
+			next(message)
+		}
+	}
+}
+
+// Logger 返回一个 MessageMiddleware,以 key=value 的形式记录每条消息的 MsgId、发送者和处理耗时
+func Logger() openwechat.MessageMiddleware {
+	return func(next openwechat.MessageHandler) openwechat.MessageHandler {
+		return func(message *openwechat.Message) {
+			start := time.Now()
+			next(message)
+			log.Printf("openwechat: handled message msg_id=%s from=%s duration=%s",
+				message.MsgId, message.FromUserName, time.Since(start))
+		}
+	}
+}
+
+// RateLimiter 是一个简单的令牌桶,用于对单个 FromUserName 的消息处理限速
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	last     map[string]time.Time
+}
+
+// NewRateLimiter 创建一个 RateLimiter,同一个用户在 interval 时间内的消息会被丢弃
+func NewRateLimiter(interval time.Duration) *RateLimiter {
+	return &RateLimiter{interval: interval, last: make(map[string]time.Time)}
+}
+
+func (r *RateLimiter) allow(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if last, ok := r.last[key]; ok && now.Sub(last) < r.interval {
+		return false
+	}
+	r.last[key] = now
+	return true
+}
+
+// RateLimit 返回一个基于 RateLimiter 的 MessageMiddleware,超过频率的消息会被直接丢弃
+func RateLimit(limiter *RateLimiter) openwechat.MessageMiddleware {
+	return func(next openwechat.MessageHandler) openwechat.MessageHandler {
+		return func(message *openwechat.Message) {
+			if !limiter.allow(message.FromUserName) {
+				return
+			}
+			next(message)
+		}
+	}
+}
+
+// Metrics 汇总消息处理速率、sync 延迟以及错误率
+type Metrics struct {
+	MessageCount  int64
+	SyncCount     int64
+	SyncErrCount  int64
+	SyncTotalTime int64 // 纳秒
+}
+
+// MessagesPerSecond 返回从 since 到现在的平均消息处理速率
+func (m *Metrics) MessagesPerSecond(since time.Time) float64 {
+	elapsed := time.Since(since).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&m.MessageCount)) / elapsed
+}
+
+// ErrorRate 返回 syncCheck 的错误率
+func (m *Metrics) ErrorRate() float64 {
+	total := atomic.LoadInt64(&m.SyncCount)
+	if total == 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&m.SyncErrCount)) / float64(total)
+}
+
+// MessageCounter 返回一个统计消息处理数量的 MessageMiddleware
+func MessageCounter(m *Metrics) openwechat.MessageMiddleware {
+	return func(next openwechat.MessageHandler) openwechat.MessageHandler {
+		return func(message *openwechat.Message) {
+			atomic.AddInt64(&m.MessageCount, 1)
+			next(message)
+		}
+	}
+}
+
+// SyncLatency 返回一个统计 syncCheck 耗时和错误率的 SyncMiddleware
+func SyncLatency(m *Metrics) openwechat.SyncMiddleware {
+	return func(next openwechat.SyncHandler) openwechat.SyncHandler {
+		return func() error {
+			start := time.Now()
+			err := next()
+			atomic.AddInt64(&m.SyncCount, 1)
+			atomic.AddInt64(&m.SyncTotalTime, int64(time.Since(start)))
+			if err != nil {
+				atomic.AddInt64(&m.SyncErrCount, 1)
+			}
+			return err
+		}
+	}
+}
+
+// lru 是一个容量固定、线程安全的最近最少使用缓存,只关心 key 是否存在过
+type lru struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+func newLRU(capacity int) *lru {
+	return &lru{capacity: capacity, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+// seen 返回 key 是否已经出现过,如果没出现过则记录下来
+func (c *lru) seen(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.ll.MoveToFront(elem)
+		return true
+	}
+	elem := c.ll.PushFront(key)
+	c.items[key] = elem
+	if c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		if oldest != nil {
+			c.ll.Remove(oldest)
+			delete(c.items, oldest.Value.(string))
+		}
+	}
+	return false
+}
+
+// Dedup 返回一个基于 LRU 的 MessageMiddleware,按 MsgId 丢弃重复投递的消息
+func Dedup(capacity int) openwechat.MessageMiddleware {
+	cache := newLRU(capacity)
+	return func(next openwechat.MessageHandler) openwechat.MessageHandler {
+		return func(message *openwechat.Message) {
+			if cache.seen(message.MsgId) {
+				return
+			}
+			next(message)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUSeen(t *testing.T) {
+	c := newLRU(2)
+
+	if c.seen("a") {
+		t.Fatal("expected \"a\" to be unseen the first time")
+	}
+	if !c.seen("a") {
+		t.Fatal("expected \"a\" to be seen the second time")
+	}
+
+	// 容量为 2, 插入 b、c 之后最久未使用的 "a" 应该被淘汰
+	c.seen("b")
+	c.seen("c")
+	if c.seen("a") {
+		t.Fatal("expected \"a\" to have been evicted once capacity was exceeded")
+	}
+}
+
+func TestLRUSeenRefreshesRecency(t *testing.T) {
+	c := newLRU(2)
+	c.seen("a")
+	c.seen("b")
+	c.seen("a") // 重新访问 a, b 变成最久未使用
+	c.seen("c") // 容量超限,应该淘汰 b 而不是 a
+
+	// 先检查 a: seen 会把缺失的 key 重新插入并触发淘汰,先查 b 会把 a 挤出去
+	if !c.seen("a") {
+		t.Fatal("expected \"a\" to still be present after being refreshed")
+	}
+	if c.seen("b") {
+		t.Fatal("expected \"b\" to have been evicted")
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	r := NewRateLimiter(50 * time.Millisecond)
+
+	if !r.allow("u1") {
+		t.Fatal("expected the first message from a user to be allowed")
+	}
+	if r.allow("u1") {
+		t.Fatal("expected an immediate second message from the same user to be dropped")
+	}
+	if !r.allow("u2") {
+		t.Fatal("expected a different user to have its own independent limit")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if !r.allow("u1") {
+		t.Fatal("expected the message to be allowed again after interval has elapsed")
+	}
+}
@@ -0,0 +1,215 @@
+// Package mpbridge 提供了一个把微信公众号(MP)菜单点击/文本消息
+// 转发给 *openwechat.Bot 的命令桥,方便在没有公网可视界面的情况下
+// 远程遥控一个无头运行的机器人
+package mpbridge
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tgNotHouse/openwechat"
+)
+
+// WechatHandler 处理一次 MP 菜单点击/文本消息
+// openID 是触发事件的公众号粉丝标识,payload 是菜单 key 或者消息正文
+type WechatHandler func(openID, payload string) (reply string, err error)
+
+// Event 是 MP 推送过来的一次菜单点击/文本消息事件
+type Event struct {
+	OpenID  string `json:"open_id"`
+	Key     string `json:"key"`     // 菜单 click 事件的 EventKey
+	Content string `json:"content"` // 文本消息内容
+	Nonce   string `json:"nonce"`   // 用于去重,同一个 nonce 只处理一次
+}
+
+// Pusher 主动推送一条文本消息给指定的 OpenID
+// 登录这类异步操作没办法在一次 HTTP 请求-响应里返回结果,
+// 需要通过 Pusher 对接 MP 客服消息接口把二维码地址等结果单独推送回去
+type Pusher func(openID, text string) error
+
+// Bridge 把 *openwechat.Bot 包装成一个可以接收 MP 回调的 http.Handler
+type Bridge struct {
+	bot *openwechat.Bot
+
+	handlers sync.Map // map[string]WechatHandler, key 为菜单 key/指令名
+
+	// Token 是在 MP 后台配置的服务器令牌,ServeHTTP 用它校验请求的 signature 参数,
+	// 拒绝没有经过 MP 服务器转发(或者伪造了 open_id)的请求
+	// 为空时 ServeHTTP 拒绝所有请求,必须显式设置才能上线,避免 SuperOpenID 的访问控制被绕过
+	Token string
+
+	// SuperOpenID 是允许执行特权指令(如 Logout、CrashReason)的 OpenID 白名单
+	SuperOpenID map[string]struct{}
+
+	// Push 用于把登录二维码等异步结果推送回触发操作的 OpenID,为 nil 时登录仍会执行,只是拿不到二维码地址
+	Push Pusher
+
+	// HotReloadStorage 设置后,loginBtn 会优先走 HotLogin 而不是每次都扫码登录
+	HotReloadStorage openwechat.HotReloadStorage
+
+	seen sync.Map // map[string]struct{}, 用于 nonce 去重
+}
+
+// New 创建一个绑定到 bot 的 Bridge,并注册内置的指令处理器
+// token 必须和 MP 后台"服务器配置"里填写的令牌一致,ServeHTTP 用它校验请求确实来自 MP 服务器,
+// 避免任何能访问到这个 HTTP 端点的人伪造 open_id 绕过 SuperOpenID 白名单
+func New(bot *openwechat.Bot, token string) *Bridge {
+	b := &Bridge{bot: bot, Token: token, SuperOpenID: map[string]struct{}{}}
+	b.registerBuiltinHandlers()
+	return b
+}
+
+// Handle 注册一个 key -> handler 的绑定,已存在的 key 会被覆盖
+func (b *Bridge) Handle(key string, handler WechatHandler) {
+	b.handlers.Store(key, handler)
+}
+
+// IsSuper 判断 openID 是否在特权指令白名单内
+func (b *Bridge) IsSuper(openID string) bool {
+	_, ok := b.SuperOpenID[openID]
+	return ok
+}
+
+// ServeHTTP 实现 http.Handler,接收 MP 服务器转发过来的 JSON 事件
+// 在解析 body、信任其中的 open_id 之前,先校验请求携带的 MP 签名,
+// 否则任何能访问到这个端点的人都可以自己构造 JSON 伪造 open_id,绕过 SuperOpenID 白名单
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !b.verifySignature(r.URL.Query()) {
+		http.Error(w, "mpbridge: invalid signature", http.StatusForbidden)
+		return
+	}
+	var event Event
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reply, err := b.dispatch(event)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, _ = w.Write([]byte(reply))
+}
+
+// verifySignature 按照 MP 服务器配置的算法校验 signature 参数:
+// 把 Token、timestamp、nonce 三者字典序排序后拼接、sha1,结果应该和 signature 一致
+// https://developers.weixin.qq.com/doc/offiaccount/Basic_Information/Access_Overview.html
+func (b *Bridge) verifySignature(query url.Values) bool {
+	if b.Token == "" {
+		return false
+	}
+	signature := query.Get("signature")
+	timestamp := query.Get("timestamp")
+	nonce := query.Get("nonce")
+	if signature == "" || timestamp == "" || nonce == "" {
+		return false
+	}
+	parts := []string{b.Token, timestamp, nonce}
+	sort.Strings(parts)
+	sum := sha1.Sum([]byte(strings.Join(parts, "")))
+	return hex.EncodeToString(sum[:]) == signature
+}
+
+// dispatch 根据事件的 key 找到对应的 handler 并执行,
+// 同一个 nonce 的事件只会被处理一次,防止 MP 服务器重试造成重复触发
+func (b *Bridge) dispatch(event Event) (string, error) {
+	if event.Nonce != "" {
+		if _, loaded := b.seen.LoadOrStore(event.Nonce, struct{}{}); loaded {
+			return "", nil
+		}
+	}
+	key := event.Key
+	if key == "" {
+		key = "text"
+	}
+	v, ok := b.handlers.Load(key)
+	if !ok {
+		return "", errors.New("mpbridge: no handler registered for key " + key)
+	}
+	handler := v.(WechatHandler)
+	payload := event.Content
+	if payload == "" {
+		payload = event.Key
+	}
+	return handler(event.OpenID, payload)
+}
+
+// registerBuiltinHandlers 注册一组镜像 Bot 操作的内置指令,
+// 对应 loginBtn/get_user/logout/get_open_id/crash_reason/text 这几个菜单 key
+func (b *Bridge) registerBuiltinHandlers() {
+	b.Handle("loginBtn", b.handleLogin)
+	b.Handle("get_user", b.handleGetUser)
+	b.Handle("logout", b.handleLogout)
+	b.Handle("get_open_id", b.handleGetOpenID)
+	b.Handle("crash_reason", b.handleCrashReason)
+	b.Handle("text", b.handleText)
+}
+
+// handleLogin 触发 Login(或者配置了 HotReloadStorage 时的 HotLogin),
+// 登录本身是异步的,二维码地址通过 Push 单独推送回触发登录的 OpenID
+func (b *Bridge) handleLogin(openID, _ string) (string, error) {
+	if b.bot.Alive() {
+		return "已经登录", nil
+	}
+	b.bot.UUIDCallback = func(uuid string) {
+		if b.Push == nil {
+			return
+		}
+		_ = b.Push(openID, openwechat.GetQrcodeUrl(uuid))
+	}
+	go func() {
+		if b.HotReloadStorage != nil {
+			_ = b.bot.HotLogin(b.HotReloadStorage)
+			return
+		}
+		_ = b.bot.Login()
+	}()
+	return "正在生成登录二维码,请稍候", nil
+}
+
+func (b *Bridge) handleGetUser(openID, _ string) (string, error) {
+	self, err := b.bot.GetCurrentUser()
+	if err != nil {
+		return "", err
+	}
+	return self.NickName, nil
+}
+
+// handleLogout 调用 Bot.Logout,让手机端和网页端同时退出登录
+func (b *Bridge) handleLogout(openID, _ string) (string, error) {
+	if !b.IsSuper(openID) {
+		return "", errors.New("mpbridge: logout requires a super openID")
+	}
+	if err := b.bot.Logout(); err != nil {
+		return "", err
+	}
+	return "已退出登录", nil
+}
+
+func (b *Bridge) handleGetOpenID(openID, _ string) (string, error) {
+	return openID, nil
+}
+
+func (b *Bridge) handleCrashReason(openID, _ string) (string, error) {
+	if !b.IsSuper(openID) {
+		return "", errors.New("mpbridge: crash_reason requires a super openID")
+	}
+	if err := b.bot.CrashReason(); err != nil {
+		return err.Error(), nil
+	}
+	return "bot 当前运行正常", nil
+}
+
+// handleText 把一段文本作为合成消息转发给 Bot 当前生效的 MessageHandler,
+// 让公众号的文本消息也能走现有的消息处理管线
+func (b *Bridge) handleText(openID, payload string) (string, error) {
+	b.bot.DispatchMessage(payload)
+	return "已转发: " + payload, nil
+}
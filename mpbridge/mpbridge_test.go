@@ -0,0 +1,76 @@
+package mpbridge
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestBridgeDispatchDedupByNonce(t *testing.T) {
+	b := &Bridge{}
+	var calls int
+	b.Handle("text", func(openID, payload string) (string, error) {
+		calls++
+		return "ok", nil
+	})
+
+	event := Event{Key: "text", Content: "hi", Nonce: "n1"}
+	if _, err := b.dispatch(event); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if _, err := b.dispatch(event); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected handler to run once for a repeated nonce, ran %d times", calls)
+	}
+
+	event.Nonce = "n2"
+	if _, err := b.dispatch(event); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected a new nonce to trigger a new call, got %d", calls)
+	}
+}
+
+func TestBridgeVerifySignature(t *testing.T) {
+	b := &Bridge{Token: "secret"}
+
+	sign := func(timestamp, nonce string) string {
+		parts := []string{b.Token, timestamp, nonce}
+		sort.Strings(parts)
+		sum := sha1.Sum([]byte(strings.Join(parts, "")))
+		return hex.EncodeToString(sum[:])
+	}
+
+	good := url.Values{
+		"timestamp": {"1234567890"},
+		"nonce":     {"abcde"},
+	}
+	good.Set("signature", sign("1234567890", "abcde"))
+	if !b.verifySignature(good) {
+		t.Fatal("expected a correctly signed request to verify")
+	}
+
+	bad := url.Values{
+		"timestamp": {"1234567890"},
+		"nonce":     {"abcde"},
+		"signature": {"not-the-right-signature"},
+	}
+	if b.verifySignature(bad) {
+		t.Fatal("expected a forged signature to be rejected")
+	}
+
+	if b.verifySignature(url.Values{}) {
+		t.Fatal("expected a request with no signature params to be rejected")
+	}
+
+	empty := &Bridge{}
+	if empty.verifySignature(good) {
+		t.Fatal("expected verification to fail when Token is not configured")
+	}
+}
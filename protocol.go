@@ -0,0 +1,139 @@
+package openwechat
+
+import "time"
+
+// iPad/Mac 长连接协议在现有 mode 枚举上追加的取值
+// 走和网页版不同的登录与消息同步通道,以降低被风控和封号的概率
+const (
+	iPad mode = iota + 100
+	mac
+)
+
+// isLongConn 判断当前协议是否是 iPad/Mac 这类常驻长连接协议
+// 网页版微信(normal/desktop)走短轮询的 syncCheck
+func (m mode) isLongConn() bool {
+	return m == iPad || m == mac
+}
+
+// IPad 将 Bot 切换为 iPad 长连接协议登录
+// 设备指纹(DeviceID/DeviceName)会在 GetQR 阶段与服务端协商,默认 DeviceName 为 "iPad"
+//
+//	bot := openwechat.DefaultBot(openwechat.IPad)
+func IPad(bot *Bot) {
+	bot.protocol = iPad
+	bot.deviceName = "iPad"
+	bot.Caller.Client.SetMode(iPad)
+}
+
+// Mac 将 Bot 切换为 Mac 长连接协议登录
+func Mac(bot *Bot) {
+	bot.protocol = mac
+	bot.deviceName = "Mac"
+	bot.Caller.Client.SetMode(mac)
+}
+
+// IPadScanLogin 对应 iPad/Mac 协议的扫码登录
+// 与网页版 SacnLogin 的区别在于二维码由 GetQR 而非 GetUUID 获取,
+// 扫码状态由 CheckQR 轮询,拿到的是一个稳定的长连接 session 而不是一次性的 uuid
+type IPadScanLogin struct{}
+
+// Login 实现 BotLogin 接口
+func (l *IPadScanLogin) Login(bot *Bot) error {
+	return bot.Caller.IPadLogin(bot)
+}
+
+// IPadHotLogin 对应 iPad/Mac 协议的热登录
+type IPadHotLogin struct {
+	storage HotReloadStorage
+	opt     hotLoginOption
+}
+
+// Login 实现 BotLogin 接口
+func (l *IPadHotLogin) Login(bot *Bot) error {
+	bot.hotReloadStorage = l.storage
+	if err := bot.reload(); err == nil && bot.protocol.isLongConn() {
+		// 恢复成功,尝试在原有的长连接 session 上继续拉取消息
+		if err = bot.Caller.IPadWakeUp(bot); err == nil {
+			return bot.WebInit()
+		}
+	}
+	return (&IPadScanLogin{}).Login(bot)
+}
+
+// longConnSyncCheck 是 iPad/Mac 协议下常驻长连接的消息同步循环,
+// 用来替代网页版短轮询的 syncCheck。
+// 连接绑定了 bot.Context(),Bot.Exit() 取消 context 时阻塞中的 Next() 会立即返回,
+// 而不会一直占着连接等服务端发下一帧数据
+func (b *Bot) longConnSyncCheck() error {
+	stream, err := b.Caller.IPadDialStream(b.Context(), b.Storage.Request, b.Storage.LoginInfo)
+	if err != nil {
+		return err
+	}
+	b.setIPadStream(stream)
+	defer b.setIPadStream(nil)
+	defer stream.Close()
+
+	heartbeatDone := make(chan struct{})
+	defer close(heartbeatDone)
+	go b.ipadHeartbeatLoop(heartbeatDone)
+
+	for b.Alive() {
+		resp, err := stream.Next()
+		if err != nil {
+			return err
+		}
+		if b.SyncCheckCallback != nil {
+			b.SyncCheckCallback(*resp)
+		}
+		if !resp.Success() {
+			return resp.Err()
+		}
+		if resp.NorMal() {
+			continue
+		}
+		// 走 iPad 专属的同步接口获取消息内容,而不是网页版容易触发风控的 WebWxSync
+		messages, err := b.ipadSyncMessage()
+		if err != nil {
+			return err
+		}
+		if b.MessageHandler == nil {
+			continue
+		}
+		for _, message := range messages {
+			message.init(b)
+			b.effectiveMessageHandler(message)
+		}
+	}
+	return nil
+}
+
+// ipadSyncMessage 是 longConnSyncCheck 专用的消息拉取,对应 Caller.IPadSyncMessage
+func (b *Bot) ipadSyncMessage() ([]*Message, error) {
+	resp, err := b.Caller.IPadSyncMessage(b.Storage.Request, b.Storage.Response)
+	if err != nil {
+		return nil, err
+	}
+	b.Storage.Response.SyncKey = resp.SyncKey
+	return resp.AddMsgList, nil
+}
+
+// ipadHeartbeatLoop 按 ipadHeartbeatInterval 周期性发送心跳,
+// 直到 stream 关闭(done)或者 bot 退出(Context 被取消)
+func (b *Bot) ipadHeartbeatLoop(done <-chan struct{}) {
+	ticker := time.NewTicker(ipadHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-b.Context().Done():
+			return
+		case <-ticker.C:
+			_ = b.Caller.IPadHeartbeat(b.Storage.Request)
+		}
+	}
+}
+
+// ipadHeartbeatInterval 长连接下客户端主动发送心跳的间隔,
+// 避免服务端因为长时间静默而断开连接
+const ipadHeartbeatInterval = 30 * time.Second
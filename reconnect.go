@@ -0,0 +1,109 @@
+package openwechat
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// ReconnectPolicy 描述 syncCheck 遇到可重试错误(网络抖动、超时、5xx)时的退避策略
+type ReconnectPolicy struct {
+	MaxAttempts  int           // 最大重试次数,0 表示不限制
+	InitialDelay time.Duration // 第一次重试前的延迟
+	MaxDelay     time.Duration // 单次延迟在叠加 Jitter 之前的上限,0 表示不限制
+	Factor       float64       // 指数退避的倍率,默认 2
+	Jitter       float64       // 0~1 之间,delay 上下浮动的比例,避免多个 bot 同时重连
+}
+
+// DefaultReconnectPolicy 是开箱即用的退避参数: 1s 起步,最多翻倍到 30s,带 20% 抖动
+func DefaultReconnectPolicy() *ReconnectPolicy {
+	return &ReconnectPolicy{
+		MaxAttempts:  0,
+		InitialDelay: time.Second,
+		MaxDelay:     30 * time.Second,
+		Factor:       2,
+		Jitter:       0.2,
+	}
+}
+
+// nextDelay 根据重试次数计算这一次应该等待多久
+func (p *ReconnectPolicy) nextDelay(attempt int) time.Duration {
+	factor := p.Factor
+	if factor <= 1 {
+		factor = 2
+	}
+	delay := float64(p.InitialDelay) * math.Pow(factor, float64(attempt-1))
+	if max := float64(p.MaxDelay); max > 0 && delay > max {
+		delay = max
+	}
+	if p.Jitter > 0 {
+		delta := delay * p.Jitter
+		delay += delta*rand.Float64()*2 - delta
+	}
+	return time.Duration(delay)
+}
+
+// isRetryable 判断一个 syncCheck 的错误是否值得退避重试而不是直接放弃
+// wechat 自身返回的业务错误码(Ret)是明确的业务拒绝(例如被踢下线、参数错误),重试没有意义,
+// 交给 MessageErrorHandler 决定去留;
+// 非 2xx 的 HTTPStatusError 只有 5xx 会重试,4xx 视为请求本身的问题,不重试;
+// 除此之外的错误(网络抖动、超时、captive portal 返回的非预期 HTML 导致的解码失败等)
+// 默认都当作传输层问题,值得退避重试 —— 不要求精确匹配某个类型,
+// 否则每新出现一种没被枚举到的瞬时错误就会重新变成死循环式的快速重试
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var ret Ret
+	if errors.As(err, &ret) {
+		return false
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode() >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// testConnect 探测微信服务器是否可达,用于区分"网络断了"和"cookie 失效了"
+func (b *Bot) testConnect() bool {
+	domain := b.Caller.Client.Domain
+	url := "https://" + domain.BaseHost() + "/"
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Head(url)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return true
+}
+
+// waitForReconnect 按照 policy 退避并在每次重试前探测网络是否恢复,
+// 网络探测成功或者达到最大重试次数后返回,交由调用方重新发起 SyncCheck
+func (b *Bot) waitForReconnect(err error) {
+	policy := b.ReconnectPolicy
+	if policy == nil {
+		policy = DefaultReconnectPolicy()
+	}
+	attempt := 0
+	for b.Alive() {
+		attempt++
+		if policy.MaxAttempts > 0 && attempt > policy.MaxAttempts {
+			return
+		}
+		delay := policy.nextDelay(attempt)
+		if b.ReconnectCallback != nil {
+			b.ReconnectCallback(attempt, err, delay)
+		}
+		select {
+		case <-b.Context().Done():
+			return
+		case <-time.After(delay):
+		}
+		if b.testConnect() {
+			return
+		}
+	}
+}
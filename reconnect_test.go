@@ -0,0 +1,62 @@
+package openwechat
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestReconnectPolicyNextDelay(t *testing.T) {
+	p := &ReconnectPolicy{InitialDelay: time.Second, MaxDelay: 4 * time.Second, Factor: 2}
+
+	if got := p.nextDelay(1); got != time.Second {
+		t.Fatalf("attempt 1: got %v, want %v", got, time.Second)
+	}
+	if got := p.nextDelay(2); got != 2*time.Second {
+		t.Fatalf("attempt 2: got %v, want %v", got, 2*time.Second)
+	}
+	if got := p.nextDelay(3); got != 4*time.Second {
+		t.Fatalf("attempt 3: got %v, want %v", got, 4*time.Second)
+	}
+	// 超过 MaxDelay 的指数增长应该被钳制住
+	if got := p.nextDelay(10); got != 4*time.Second {
+		t.Fatalf("attempt 10: got %v, want clamped %v", got, 4*time.Second)
+	}
+}
+
+func TestReconnectPolicyNextDelayJitter(t *testing.T) {
+	p := &ReconnectPolicy{InitialDelay: 10 * time.Second, MaxDelay: 0, Factor: 2, Jitter: 0.5}
+	low := 5 * time.Second
+	high := 15 * time.Second
+	for i := 0; i < 20; i++ {
+		delay := p.nextDelay(1)
+		if delay < low || delay > high {
+			t.Fatalf("jittered delay %v out of expected range [%v, %v]", delay, low, high)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"ret", Ret(1101), false},
+		{"status 5xx", &HTTPStatusError{Path: "/x", Code: http.StatusBadGateway}, true},
+		{"status 4xx", &HTTPStatusError{Path: "/x", Code: http.StatusBadRequest}, false},
+		{"eof", io.EOF, true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"unclassified", errors.New("captive portal returned unexpected html"), true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isRetryable(c.err); got != c.want {
+				t.Fatalf("isRetryable(%v) = %v, want %v", c.err, got, c.want)
+			}
+		})
+	}
+}